@@ -0,0 +1,173 @@
+// Package auth adds optional authentication and per-directory access
+// control on top of the plain file server: HTTP Basic or bearer-token
+// login against a user file, and a gohttpserver-style ".fileshare.yml"
+// overlay that scopes read/write access to subtrees of BasePath.
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+// User is one entry of the auth file: a login identity with a bcrypt
+// password hash and, optionally, a static bearer token.
+type User struct {
+	Name         string `json:"name" yaml:"name"`
+	PasswordHash string `json:"password_hash" yaml:"password_hash"`
+	Token        string `json:"token,omitempty" yaml:"token,omitempty"`
+}
+
+// Rule is the content of a per-directory ".fileshare.yml" overlay.
+type Rule struct {
+	Read   []string `json:"read,omitempty" yaml:"read,omitempty"`
+	Write  []string `json:"write,omitempty" yaml:"write,omitempty"`
+	Public bool     `json:"public,omitempty" yaml:"public,omitempty"`
+}
+
+const overlayFileName = ".fileshare.yml"
+
+// Store holds the loaded user file and answers authentication and
+// authorization questions against it.
+type Store struct {
+	users   map[string]User
+	byToken map[string]User
+}
+
+// LoadUsers reads a user file (YAML or JSON, selected by extension) of
+// either a top-level array of User or {"users": [...]}.
+func LoadUsers(path string) (*Store, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc struct {
+		Users []User `json:"users" yaml:"users"`
+	}
+	unmarshal := yaml.Unmarshal
+	if strings.HasSuffix(path, ".json") {
+		unmarshal = json.Unmarshal
+	}
+	// Try a top-level array first, then fall back to {"users": [...]}.
+	if unmarshal(data, &doc.Users) != nil {
+		if err := unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &Store{users: map[string]User{}, byToken: map[string]User{}}
+	for _, u := range doc.Users {
+		s.users[u.Name] = u
+		if u.Token != "" {
+			s.byToken[u.Token] = u
+		}
+	}
+	return s, nil
+}
+
+type contextKey int
+
+const userContextKey contextKey = 0
+
+// Middleware authenticates each request, if credentials are present, and
+// attaches the resulting username (or "" for anonymous/invalid
+// credentials) to the request context. It never rejects a request by
+// itself: a missing or bad credential only matters once a handler calls
+// Authorize and finds the path isn't public.
+func (s *Store) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _ := s.authenticate(r)
+		ctx := context.WithValue(r.Context(), userContextKey, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// UserFromContext returns the username attached by Middleware, if any.
+func UserFromContext(ctx context.Context) (string, bool) {
+	u, ok := ctx.Value(userContextKey).(string)
+	return u, ok && u != ""
+}
+
+func (s *Store) authenticate(r *http.Request) (string, bool) {
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		token := strings.TrimPrefix(h, "Bearer ")
+		if u, ok := s.byToken[token]; ok {
+			return u.Name, true
+		}
+		return "", false
+	}
+
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return "", false
+	}
+	u, ok := s.users[username]
+	if !ok {
+		return "", false
+	}
+	if bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) != nil {
+		return "", false
+	}
+	return username, true
+}
+
+// Authorize reports whether user may access path (a BasePath-rooted path
+// as returned by the server's validatePath) for read, or for write if
+// write is true. It consults the nearest ".fileshare.yml" found walking up
+// from path's directory to basePath; with no overlay present anywhere in
+// that chain, any authenticated user is allowed and anonymous users are
+// not.
+func (s *Store) Authorize(user, path, basePath string, write bool) bool {
+	dir := strings.TrimSuffix(path, "/")
+	if !strings.HasSuffix(path, "/") {
+		dir = filepath.Dir(path)
+	}
+
+	rule, ok := lookupRule(dir, basePath)
+	if !ok {
+		return user != ""
+	}
+	if rule.Public && !write {
+		return true
+	}
+
+	list := rule.Read
+	if write {
+		list = rule.Write
+	}
+	for _, allowed := range list {
+		if allowed == "*" || allowed == user {
+			return true
+		}
+	}
+	return false
+}
+
+func lookupRule(dir, basePath string) (Rule, bool) {
+	basePath = filepath.Clean(basePath)
+	for {
+		if data, err := os.ReadFile(filepath.Join(dir, overlayFileName)); err == nil {
+			var r Rule
+			if yaml.Unmarshal(data, &r) == nil {
+				return r, true
+			}
+		}
+
+		if dir == basePath {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return Rule{}, false
+}
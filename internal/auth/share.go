@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+var (
+	ErrShareNotFound  = errors.New("share link not found")
+	ErrShareExpired   = errors.New("share link expired")
+	ErrShareExhausted = errors.New("share link download limit reached")
+)
+
+var sharesBucket = []byte("shares")
+
+// ShareLink is the persisted state of one signed, one-time or
+// time-limited share URL.
+type ShareLink struct {
+	Path         string    `json:"path"`
+	Expires      time.Time `json:"expires"`
+	MaxDownloads int       `json:"max_downloads"` // 0 means unlimited
+	Downloads    int       `json:"downloads"`
+}
+
+// ShareManager issues and redeems signed share links, persisting their
+// download counters in BoltDB so restarts don't reset limits.
+type ShareManager struct {
+	secret []byte
+	db     *bbolt.DB
+}
+
+// NewShareManager opens (creating if needed) a BoltDB file at dbPath for
+// share-link state, signing new tokens with secret.
+func NewShareManager(secret []byte, dbPath string) (*ShareManager, error) {
+	db, err := bbolt.Open(dbPath, 0o600, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(sharesBucket)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ShareManager{secret: secret, db: db}, nil
+}
+
+// Create signs a new share link for path and persists it, returning its
+// token (the path component of its /s/{token} URL). A zero expires means
+// the link never expires on its own.
+func (m *ShareManager) Create(path string, expires time.Time, maxDownloads int) (string, error) {
+	token := m.sign(path, expires, maxDownloads)
+	link := ShareLink{Path: path, Expires: expires, MaxDownloads: maxDownloads}
+
+	data, err := json.Marshal(link)
+	if err != nil {
+		return "", err
+	}
+	if err := m.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(sharesBucket).Put([]byte(token), data)
+	}); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Resolve validates and redeems one download against token, atomically
+// incrementing its counter so concurrent requests can't exceed
+// MaxDownloads.
+func (m *ShareManager) Resolve(token string) (ShareLink, error) {
+	var link ShareLink
+	err := m.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(sharesBucket)
+		data := b.Get([]byte(token))
+		if data == nil {
+			return ErrShareNotFound
+		}
+		if err := json.Unmarshal(data, &link); err != nil {
+			return err
+		}
+		if !link.Expires.IsZero() && time.Now().After(link.Expires) {
+			return ErrShareExpired
+		}
+		if link.MaxDownloads > 0 && link.Downloads >= link.MaxDownloads {
+			return ErrShareExhausted
+		}
+
+		link.Downloads++
+		updated, err := json.Marshal(link)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(token), updated)
+	})
+	return link, err
+}
+
+// sign derives an unguessable, tamper-resistant token from a share link's
+// fields via HMAC-SHA256, so a token can't be forged or mutated without
+// the server's secret even though its backing record lives in BoltDB.
+func (m *ShareManager) sign(path string, expires time.Time, maxDownloads int) string {
+	mac := hmac.New(sha256.New, m.secret)
+	_, _ = fmt.Fprintf(mac, "%s|%d|%d", path, expires.Unix(), maxDownloads)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShareManagerResolveConcurrentRedeem(t *testing.T) {
+	mgr, err := NewShareManager([]byte("secret"), filepath.Join(t.TempDir(), "shares.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const maxDownloads = 5
+	token, err := mgr.Create("file.txt", time.Time{}, maxDownloads)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const attempts = 20
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded, exhausted := 0, 0
+
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := mgr.Resolve(token)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				succeeded++
+			case errors.Is(err, ErrShareExhausted):
+				exhausted++
+			default:
+				t.Errorf("unexpected Resolve error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if succeeded != maxDownloads {
+		t.Fatalf("succeeded = %d, want %d", succeeded, maxDownloads)
+	}
+	if exhausted != attempts-maxDownloads {
+		t.Fatalf("exhausted = %d, want %d", exhausted, attempts-maxDownloads)
+	}
+
+	link, err := mgr.Resolve(token)
+	if !errors.Is(err, ErrShareExhausted) {
+		t.Fatalf("final Resolve: got (%v, %v), want ErrShareExhausted", link, err)
+	}
+}
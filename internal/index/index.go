@@ -0,0 +1,150 @@
+// Package index maintains an in-memory, periodically refreshed listing of
+// every file under a root directory, modeled on the background makeIndex
+// walker used by gohttpserver, so that full-text-ish searches don't have
+// to touch disk on every request.
+package index
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileItem is a single entry in the index.
+type FileItem struct {
+	Path string // slash-separated, relative to the index root
+	Info fs.FileInfo
+}
+
+// Index walks a root directory on a timer and serves searches against the
+// most recently built snapshot.
+type Index struct {
+	root     string
+	interval time.Duration
+	maxSize  int
+
+	mu    sync.RWMutex
+	items []FileItem
+}
+
+// New creates an Index for root. interval of zero (or less) disables the
+// periodic refresh: Start then builds the index once and returns.
+// maxSize of zero means unlimited.
+func New(root string, interval time.Duration, maxSize int) *Index {
+	return &Index{root: root, interval: interval, maxSize: maxSize}
+}
+
+// Start builds the index once and then rebuilds it every interval until
+// stop is closed. It blocks, so callers should run it in a goroutine.
+func (idx *Index) Start(stop <-chan struct{}) {
+	idx.build()
+	if idx.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(idx.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			idx.build()
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (idx *Index) build() {
+	items := make([]FileItem, 0, 1024)
+	_ = filepath.WalkDir(idx.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // skip unreadable entries rather than aborting the walk
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if idx.maxSize > 0 && len(items) >= idx.maxSize {
+			return filepath.SkipAll
+		}
+
+		rel, err := filepath.Rel(idx.root, p)
+		if err != nil {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		items = append(items, FileItem{Path: filepath.ToSlash(rel), Info: info})
+		return nil
+	})
+
+	idx.mu.Lock()
+	idx.items = items
+	idx.mu.Unlock()
+}
+
+// Query scopes and filters a Search call.
+type Query struct {
+	Term   string // substring or, if Regexp is set, pattern to match against the base name
+	Path   string // restrict results to this subtree (slash-separated, relative to root)
+	Ext    string // restrict results to this extension, e.g. ".txt"
+	Limit  int    // 0 means unlimited
+	Regexp bool
+}
+
+// Search returns index entries matching q, most recently built snapshot.
+func (idx *Index) Search(q Query) ([]FileItem, error) {
+	var re *regexp.Regexp
+	if q.Regexp && q.Term != "" {
+		var err error
+		re, err = regexp.Compile(q.Term)
+		if err != nil {
+			return nil, errors.New("invalid regexp: " + err.Error())
+		}
+	}
+	term := strings.ToLower(q.Term)
+	prefix := strings.Trim(filepath.ToSlash(q.Path), "/")
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	hits := make([]FileItem, 0)
+	for _, item := range idx.items {
+		if prefix != "" && item.Path != prefix && !strings.HasPrefix(item.Path, prefix+"/") {
+			continue
+		}
+		if q.Ext != "" && filepath.Ext(item.Path) != q.Ext {
+			continue
+		}
+		name := filepath.Base(item.Path)
+		switch {
+		case q.Term == "":
+			// no term: path/ext filters alone decide the match
+		case re != nil:
+			if !re.MatchString(name) {
+				continue
+			}
+		default:
+			if !strings.Contains(strings.ToLower(name), term) {
+				continue
+			}
+		}
+
+		hits = append(hits, item)
+		if q.Limit > 0 && len(hits) >= q.Limit {
+			break
+		}
+	}
+	return hits, nil
+}
@@ -0,0 +1,97 @@
+package scan
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// clamdChunkSize is the size of each INSTREAM chunk sent to clamd; any
+// size up to clamd's StreamMaxLength works.
+const clamdChunkSize = 4096
+
+// ClamAV scans streams via clamd's INSTREAM command
+// (https://linux.die.net/man/8/clamd), connecting over TCP or a Unix
+// domain socket.
+type ClamAV struct {
+	// Addr is a "host:port" TCP address, or a filesystem path naming a
+	// Unix domain socket (e.g. "/var/run/clamav/clamd.ctl").
+	Addr string
+	// Timeout bounds the dial and the whole scan; zero means no timeout.
+	Timeout time.Duration
+}
+
+// NewClamAV returns a Scanner backed by a clamd instance at addr.
+func NewClamAV(addr string, timeout time.Duration) *ClamAV {
+	return &ClamAV{Addr: addr, Timeout: timeout}
+}
+
+func (c *ClamAV) dial() (net.Conn, error) {
+	network := "tcp"
+	if strings.HasPrefix(c.Addr, "/") {
+		network = "unix"
+	}
+	return net.DialTimeout(network, c.Addr, c.Timeout)
+}
+
+// Scan streams r to clamd using INSTREAM and reports whether it found a
+// match.
+func (c *ClamAV) Scan(r io.Reader) (Result, error) {
+	conn, err := c.dial()
+	if err != nil {
+		return Result{}, err
+	}
+	defer func() { _ = conn.Close() }()
+
+	if c.Timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(c.Timeout))
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return Result{}, err
+	}
+
+	buf := make([]byte, clamdChunkSize)
+	for {
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			size := make([]byte, 4)
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(size); err != nil {
+				return Result{}, err
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				return Result{}, err
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return Result{}, readErr
+		}
+	}
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil { // zero-length chunk terminates the stream
+		return Result{}, err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && err != io.EOF {
+		return Result{}, err
+	}
+	reply = strings.TrimRight(reply, "\x00\n")
+
+	switch {
+	case strings.HasSuffix(reply, "OK"):
+		return Result{}, nil
+	case strings.Contains(reply, "FOUND"):
+		sig := strings.TrimSuffix(strings.TrimPrefix(reply, "stream: "), " FOUND")
+		return Result{Infected: true, Signature: sig}, nil
+	default:
+		return Result{}, fmt.Errorf("clamd: unexpected reply %q", reply)
+	}
+}
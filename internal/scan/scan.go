@@ -0,0 +1,18 @@
+// Package scan defines a pluggable interface for scanning uploaded
+// content for malware before it is accepted onto disk.
+package scan
+
+import "io"
+
+// Result is the outcome of scanning a stream.
+type Result struct {
+	Infected  bool
+	Signature string // populated when Infected is true
+}
+
+// Scanner checks a stream of bytes for malware. Implementations must read
+// r to completion (or to whatever limit the caller has imposed on r)
+// before returning.
+type Scanner interface {
+	Scan(r io.Reader) (Result, error)
+}
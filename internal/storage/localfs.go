@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// LocalFS is a Storage backed directly by a local directory, preserving
+// the server's original on-disk semantics.
+type LocalFS struct {
+	root string
+}
+
+// NewLocalFS returns a Storage rooted at root.
+func NewLocalFS(root string) *LocalFS { return &LocalFS{root: root} }
+
+func (l *LocalFS) full(p string) string { return filepath.Join(l.root, p) }
+
+func (l *LocalFS) Stat(p string) (fs.FileInfo, error) { return os.Stat(l.full(p)) }
+
+func (l *LocalFS) ReadDir(p string) ([]fs.DirEntry, error) { return os.ReadDir(l.full(p)) }
+
+func (l *LocalFS) Open(p string) (io.ReadSeekCloser, error) { return os.Open(l.full(p)) }
+
+func (l *LocalFS) Create(p string) (io.WriteCloser, error) {
+	full := l.full(p)
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		return nil, err
+	}
+	return os.Create(full)
+}
+
+func (l *LocalFS) Remove(p string) error { return os.Remove(l.full(p)) }
+
+// Rename refuses to overwrite an existing file at newPath, matching the
+// Storage interface's contract: it links the new name in (which fails if
+// newPath already exists) and only then removes the old one, rather than
+// using os.Rename, which would silently clobber it.
+func (l *LocalFS) Rename(oldPath, newPath string) error {
+	full := l.full(newPath)
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		return err
+	}
+	if err := os.Link(l.full(oldPath), full); err != nil {
+		return err
+	}
+	return os.Remove(l.full(oldPath))
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalFSRenameRefusesOverwrite(t *testing.T) {
+	root := t.TempDir()
+	lfs := NewLocalFS(root)
+
+	if err := os.WriteFile(filepath.Join(root, "old.txt"), []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "new.txt"), []byte("new"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := lfs.Rename("old.txt", "new.txt"); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("Rename onto existing file: got %v, want an ErrExist-ish error", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, "new.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "new" {
+		t.Fatalf("destination contents = %q, want unchanged %q", data, "new")
+	}
+	if _, err := os.Stat(filepath.Join(root, "old.txt")); err != nil {
+		t.Fatalf("source file should still exist after a refused rename: %v", err)
+	}
+}
+
+func TestLocalFSRenameMoves(t *testing.T) {
+	root := t.TempDir()
+	lfs := NewLocalFS(root)
+
+	if err := os.WriteFile(filepath.Join(root, "old.txt"), []byte("hi"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := lfs.Rename("old.txt", "sub/new.txt"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "old.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("old path should be gone, got err %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(root, "sub", "new.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hi" {
+		t.Fatalf("moved contents = %q, want %q", data, "hi")
+	}
+}
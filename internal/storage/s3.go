@@ -0,0 +1,300 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// S3 is a Storage backed by an S3 (or S3-compatible) bucket, scoped to an
+// optional key prefix.
+type S3 struct {
+	client   *s3.Client
+	uploader *manager.Uploader
+	bucket   string
+	prefix   string
+}
+
+// NewS3 builds an S3 backend for bucket, scoping every path under prefix.
+// Credentials and region, unless overridden here, come from the standard
+// AWS SDK default chain (env vars, shared config, instance profile, ...).
+func NewS3(bucket, prefix, region string) (*S3, error) {
+	cfg, err := config.LoadDefaultConfig(context.Background(), func(o *config.LoadOptions) error {
+		if region != "" {
+			o.Region = region
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	client := s3.NewFromConfig(cfg)
+	return &S3{
+		client:   client,
+		uploader: manager.NewUploader(client),
+		bucket:   bucket,
+		prefix:   strings.Trim(prefix, "/"),
+	}, nil
+}
+
+func (s *S3) key(p string) string {
+	p = strings.TrimPrefix(p, "/")
+	if s.prefix == "" {
+		return p
+	}
+	if p == "" {
+		return s.prefix
+	}
+	return s.prefix + "/" + p
+}
+
+func (s *S3) Stat(p string) (fs.FileInfo, error) {
+	key := s.key(p)
+	ctx := context.Background()
+
+	if out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}); err == nil {
+		return s3FileInfo{name: path.Base(key), size: aws.ToInt64(out.ContentLength), modTime: aws.ToTime(out.LastModified)}, nil
+	}
+
+	// Not a single object: does it exist as a "directory", i.e. is there
+	// any object under key+"/"?
+	listOut, err := s.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(s.bucket),
+		Prefix:  aws.String(key + "/"),
+		MaxKeys: aws.Int32(1),
+	})
+	if err == nil && len(listOut.Contents) > 0 {
+		return s3FileInfo{name: path.Base(key), isDir: true}, nil
+	}
+	return nil, fs.ErrNotExist
+}
+
+func (s *S3) ReadDir(p string) ([]fs.DirEntry, error) {
+	prefix := s.key(p)
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	var entries []fs.DirEntry
+	var token *string
+	ctx := context.Background()
+	for {
+		out, err := s.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket:            aws.String(s.bucket),
+			Prefix:            aws.String(prefix),
+			Delimiter:         aws.String("/"),
+			ContinuationToken: token,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, cp := range out.CommonPrefixes {
+			name := strings.TrimSuffix(strings.TrimPrefix(aws.ToString(cp.Prefix), prefix), "/")
+			entries = append(entries, s3DirEntry{s3FileInfo{name: name, isDir: true}})
+		}
+		for _, obj := range out.Contents {
+			name := strings.TrimPrefix(aws.ToString(obj.Key), prefix)
+			if name == "" {
+				continue // the "directory marker" object itself, if any
+			}
+			entries = append(entries, s3DirEntry{s3FileInfo{name: name, size: aws.ToInt64(obj.Size), modTime: aws.ToTime(obj.LastModified)}})
+		}
+
+		if !aws.ToBool(out.IsTruncated) {
+			break
+		}
+		token = out.NextContinuationToken
+	}
+	return entries, nil
+}
+
+func (s *S3) Open(p string) (io.ReadSeekCloser, error) {
+	key := s.key(p)
+	out, err := s.client.HeadObject(context.Background(), &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &s3Reader{client: s.client, bucket: s.bucket, key: key, size: aws.ToInt64(out.ContentLength)}, nil
+}
+
+// Create streams writes into a background multipart Upload via an
+// io.Pipe, so large uploads reach S3 without buffering in memory.
+func (s *S3) Create(p string) (io.WriteCloser, error) {
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.uploader.Upload(context.Background(), &s3.PutObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(s.key(p)),
+			Body:   pr,
+		})
+		_ = pr.CloseWithError(err)
+		done <- err
+	}()
+	return &s3Writer{pw: pw, done: done}, nil
+}
+
+func (s *S3) Remove(p string) error {
+	_, err := s.client.DeleteObject(context.Background(), &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(p)),
+	})
+	return err
+}
+
+// Rename implements the local backend's collision-avoidance guarantee for
+// S3, which has no native rename: it copies oldPath's bytes onto newPath
+// only if newPath does not already exist. The HeadObject check above the
+// PutObject is inherently racy between two concurrent renames, so the
+// PutObject also carries an If-None-Match: * condition as the real guard.
+func (s *S3) Rename(oldPath, newPath string) error {
+	ctx := context.Background()
+	oldKey, newKey := s.key(oldPath), s.key(newPath)
+
+	if _, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(newKey)}); err == nil {
+		return fmt.Errorf("s3: %s already exists", newPath)
+	}
+
+	obj, err := s.client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(oldKey)})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = obj.Body.Close() }()
+
+	if _, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(newKey),
+		Body:        obj.Body,
+		IfNoneMatch: aws.String("*"),
+	}); err != nil {
+		return fmt.Errorf("s3: rename %s -> %s: %w", oldPath, newPath, err)
+	}
+
+	_, err = s.client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(oldKey)})
+	return err
+}
+
+type s3FileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	isDir   bool
+}
+
+func (i s3FileInfo) Name() string { return i.name }
+func (i s3FileInfo) Size() int64  { return i.size }
+func (i s3FileInfo) Mode() fs.FileMode {
+	if i.isDir {
+		return fs.ModeDir | 0o755
+	}
+	return 0o644
+}
+func (i s3FileInfo) ModTime() time.Time { return i.modTime }
+func (i s3FileInfo) IsDir() bool        { return i.isDir }
+func (i s3FileInfo) Sys() any           { return nil }
+
+type s3DirEntry struct{ info s3FileInfo }
+
+func (e s3DirEntry) Name() string              { return e.info.name }
+func (e s3DirEntry) IsDir() bool               { return e.info.isDir }
+func (e s3DirEntry) Type() fs.FileMode         { return e.info.Mode().Type() }
+func (e s3DirEntry) Info() (fs.FileInfo, error) { return e.info, nil }
+
+// s3Reader satisfies io.ReadSeekCloser by issuing ranged GetObject requests
+// as needed, so http.ServeContent can serve range/partial-content
+// responses without buffering the whole object in memory.
+type s3Reader struct {
+	client      *s3.Client
+	bucket, key string
+	size        int64
+	pos         int64
+	body        io.ReadCloser
+}
+
+func (r *s3Reader) Read(p []byte) (int, error) {
+	if r.body == nil {
+		if err := r.openAt(r.pos); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.body.Read(p)
+	r.pos += int64(n)
+	return n, err
+}
+
+func (r *s3Reader) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = r.pos + offset
+	case io.SeekEnd:
+		pos = r.size + offset
+	default:
+		return 0, errors.New("s3: invalid whence")
+	}
+	if pos < 0 {
+		return 0, errors.New("s3: negative seek position")
+	}
+	if pos != r.pos && r.body != nil {
+		_ = r.body.Close()
+		r.body = nil
+	}
+	r.pos = pos
+	return pos, nil
+}
+
+func (r *s3Reader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	return r.body.Close()
+}
+
+func (r *s3Reader) openAt(offset int64) error {
+	out, err := r.client.GetObject(context.Background(), &s3.GetObjectInput{
+		Bucket: aws.String(r.bucket),
+		Key:    aws.String(r.key),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-", offset)),
+	})
+	if err != nil {
+		return err
+	}
+	r.body = out.Body
+	return nil
+}
+
+// s3Writer adapts an io.Pipe to io.WriteCloser, surfacing the background
+// Upload's error (if any) from Close.
+type s3Writer struct {
+	pw   *io.PipeWriter
+	done chan error
+}
+
+func (w *s3Writer) Write(p []byte) (int, error) { return w.pw.Write(p) }
+
+func (w *s3Writer) Close() error {
+	if err := w.pw.Close(); err != nil {
+		return err
+	}
+	return <-w.done
+}
@@ -0,0 +1,60 @@
+// Package storage abstracts the filesystem operations the HTTP handlers
+// need — stat, list, read, write, remove, rename — behind a single
+// interface, so the directory a fileshare instance serves can live on
+// local disk or in an object store without the handlers knowing which.
+package storage
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/url"
+	"strings"
+)
+
+// Storage is the set of operations a backend must provide. Every path
+// argument is slash-separated and relative to the backend's configured
+// root (a local directory, or an S3 bucket+prefix).
+type Storage interface {
+	// Stat reports metadata for path, or an error satisfying
+	// errors.Is(err, fs.ErrNotExist) if it does not exist.
+	Stat(path string) (fs.FileInfo, error)
+	// ReadDir lists the immediate children of the directory at path.
+	ReadDir(path string) ([]fs.DirEntry, error)
+	// Open returns a seekable reader over the file at path.
+	Open(path string) (io.ReadSeekCloser, error)
+	// Create returns a writer for a new file at path, creating any
+	// missing parent directories/prefixes.
+	Create(path string) (io.WriteCloser, error)
+	// Remove deletes the file at path.
+	Remove(path string) error
+	// Rename moves the file at oldPath to newPath, refusing to overwrite
+	// an existing file there.
+	Rename(oldPath, newPath string) error
+}
+
+// Open constructs the backend named by driver, pointed at source, a
+// URL-like string: "file:///srv/data" for the local backend,
+// "s3://bucket/prefix?region=us-east-1" for the S3 backend. An empty
+// driver defaults to "localfs".
+func Open(driver, source string) (Storage, error) {
+	switch driver {
+	case "", "localfs":
+		root := source
+		if u, err := url.Parse(source); err == nil && u.Scheme == "file" {
+			root = u.Path
+		}
+		return NewLocalFS(root), nil
+	case "s3":
+		u, err := url.Parse(source)
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme != "s3" {
+			return nil, errors.New("storage: s3 source must look like s3://bucket/prefix")
+		}
+		return NewS3(u.Host, strings.TrimPrefix(u.Path, "/"), u.Query().Get("region"))
+	default:
+		return nil, errors.New("storage: unknown driver " + driver)
+	}
+}
@@ -0,0 +1,291 @@
+// Package upload implements the core of the tus 1.0 resumable upload
+// protocol (https://tus.io/protocols/resumable-upload): an upload is
+// created, then extended with sequential chunks until its offset reaches
+// its declared size.
+//
+// Each upload is tracked as a pair of files under the manager's staging
+// directory: a `<id>.part` file holding the bytes received so far and a
+// `<id>.json` sidecar recording offset/size/metadata, so an in-progress
+// upload can be resumed after a server restart.
+package upload
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotFound         = errors.New("upload not found")
+	ErrOffsetMismatch   = errors.New("offset mismatch")
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	ErrIncomplete       = errors.New("upload incomplete")
+	ErrTooLarge         = errors.New("chunk exceeds declared upload size")
+)
+
+// Info is the persisted state of a single upload.
+type Info struct {
+	ID       string            `json:"id"`
+	Size     int64             `json:"size"`
+	Offset   int64             `json:"offset"`
+	Filename string            `json:"filename"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Created  time.Time         `json:"created"`
+}
+
+// Manager tracks in-progress uploads under a staging directory.
+type Manager struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewManager creates a Manager, creating dir if it does not yet exist.
+func NewManager(dir string) (*Manager, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Manager{dir: dir}, nil
+}
+
+func (m *Manager) partPath(id string) string { return filepath.Join(m.dir, id+".part") }
+func (m *Manager) infoPath(id string) string { return filepath.Join(m.dir, id+".json") }
+
+// Create registers a new upload of the given total size, decoding the
+// Upload-Metadata header (tus key/base64-value pairs) into Info.Metadata.
+// The "filename" key, if present, seeds Info.Filename.
+func (m *Manager) Create(size int64, metadataHeader string) (*Info, error) {
+	if size < 0 {
+		return nil, errors.New("negative upload size")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := newID()
+	meta := parseMetadata(metadataHeader)
+	info := &Info{
+		ID:       id,
+		Size:     size,
+		Filename: meta["filename"],
+		Metadata: meta,
+		Created:  time.Now(),
+	}
+
+	f, err := os.Create(m.partPath(id))
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := m.saveInfo(info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Get returns the current state of an upload.
+func (m *Manager) Get(id string) (*Info, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.loadInfo(id)
+}
+
+// WriteChunk appends r to the upload at id, starting at offset, which must
+// equal the upload's current offset. If checksumHeader (a tus
+// Upload-Checksum value, e.g. "sha256 <base64>") is non-empty, the chunk is
+// hashed while it is written and rejected on mismatch.
+func (m *Manager) WriteChunk(id string, offset int64, r io.Reader, checksumHeader string) (*Info, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	info, err := m.loadInfo(id)
+	if err != nil {
+		return nil, err
+	}
+	if offset != info.Offset {
+		return nil, ErrOffsetMismatch
+	}
+
+	f, err := os.OpenFile(m.partPath(id), os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	// Read one byte past what the upload still has room for, so an
+	// oversized chunk is detected instead of silently written past
+	// info.Size.
+	maxChunk := info.Size - info.Offset
+	body := io.Reader(io.LimitReader(r, maxChunk+1))
+	verify := func() error { return nil }
+	if checksumHeader != "" {
+		body, verify, err = withChecksum(body, checksumHeader)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	n, err := io.Copy(f, body)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxChunk {
+		// Drop what the rejected chunk wrote, including the byte past info.Size.
+		if err := f.Truncate(info.Offset); err != nil {
+			return nil, err
+		}
+		return nil, ErrTooLarge
+	}
+	if err := verify(); err != nil {
+		return nil, err
+	}
+
+	info.Offset += n
+	if err := m.saveInfo(info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Complete validates that the upload at id has received all of its
+// declared bytes and returns its info and the path of the staged file.
+func (m *Manager) Complete(id string) (*Info, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	info, err := m.loadInfo(id)
+	if err != nil {
+		return nil, "", err
+	}
+	if info.Offset != info.Size {
+		return nil, "", ErrIncomplete
+	}
+	return info, m.partPath(id), nil
+}
+
+// Finalize removes the staging files for a completed upload.
+func (m *Manager) Finalize(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_ = os.Remove(m.partPath(id))
+	return os.Remove(m.infoPath(id))
+}
+
+// Terminate abandons an upload, implementing the tus termination
+// extension: its staging files are removed regardless of progress.
+func (m *Manager) Terminate(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_ = os.Remove(m.partPath(id))
+	if err := os.Remove(m.infoPath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (m *Manager) loadInfo(id string) (*Info, error) {
+	b, err := os.ReadFile(m.infoPath(id))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	var info Info
+	if err := json.Unmarshal(b, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+func (m *Manager) saveInfo(info *Info) error {
+	b, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	tmp := m.infoPath(info.ID) + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.infoPath(info.ID))
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// parseMetadata decodes a tus Upload-Metadata header: a comma-separated
+// list of "key base64(value)" pairs, value optional.
+func parseMetadata(header string) map[string]string {
+	meta := map[string]string{}
+	if header == "" {
+		return meta
+	}
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, " ", 2)
+		val := ""
+		if len(parts) == 2 {
+			if b, err := base64.StdEncoding.DecodeString(parts[1]); err == nil {
+				val = string(b)
+			}
+		}
+		meta[parts[0]] = val
+	}
+	return meta
+}
+
+// withChecksum wraps r so that its bytes are hashed as they are read,
+// returning a verify func that checks the running hash against the
+// algorithm/value pair carried by an Upload-Checksum header.
+func withChecksum(r io.Reader, header string) (io.Reader, func() error, error) {
+	parts := strings.SplitN(header, " ", 2)
+	if len(parts) != 2 {
+		return nil, nil, errors.New("invalid Upload-Checksum header")
+	}
+	algo, want := strings.ToLower(parts[0]), parts[1]
+
+	var h interface {
+		io.Writer
+		Sum([]byte) []byte
+	}
+	switch algo {
+	case "sha256":
+		h = sha256.New()
+	case "crc32c":
+		h = crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	default:
+		return nil, nil, fmt.Errorf("unsupported checksum algorithm: %s", algo)
+	}
+
+	tee := io.TeeReader(r, h)
+	verify := func() error {
+		if base64.StdEncoding.EncodeToString(h.Sum(nil)) != want {
+			return ErrChecksumMismatch
+		}
+		return nil
+	}
+	return tee, verify, nil
+}
@@ -0,0 +1,118 @@
+package upload
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestManagerWriteChunkOffsets(t *testing.T) {
+	m, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := m.Create(12, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.WriteChunk(info.ID, 0, strings.NewReader("hello "), ""); err != nil {
+		t.Fatalf("first chunk: %v", err)
+	}
+
+	if _, err := m.WriteChunk(info.ID, 0, strings.NewReader("oops"), ""); !errors.Is(err, ErrOffsetMismatch) {
+		t.Fatalf("stale offset: got %v, want ErrOffsetMismatch", err)
+	}
+
+	got, err := m.WriteChunk(info.ID, 6, strings.NewReader("world!"), "")
+	if err != nil {
+		t.Fatalf("second chunk: %v", err)
+	}
+	if got.Offset != 12 {
+		t.Fatalf("offset = %d, want 12", got.Offset)
+	}
+
+	_, partPath, err := m.Complete(info.ID)
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	data, err := os.ReadFile(partPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello world!" {
+		t.Fatalf("staged contents = %q", data)
+	}
+}
+
+func TestManagerWriteChunkIncomplete(t *testing.T) {
+	m, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := m.Create(10, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.WriteChunk(info.ID, 0, strings.NewReader("short"), ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := m.Complete(info.ID); !errors.Is(err, ErrIncomplete) {
+		t.Fatalf("Complete on short upload: got %v, want ErrIncomplete", err)
+	}
+}
+
+func TestManagerWriteChunkTooLarge(t *testing.T) {
+	m, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := m.Create(5, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.WriteChunk(info.ID, 0, strings.NewReader("way too much data"), ""); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("oversized chunk: got %v, want ErrTooLarge", err)
+	}
+
+	// The rejected write must not have advanced the offset, so a
+	// correctly-sized retry from the same offset still succeeds.
+	got, err := m.WriteChunk(info.ID, 0, strings.NewReader("hello"), "")
+	if err != nil {
+		t.Fatalf("retry after oversized chunk: %v", err)
+	}
+	if got.Offset != 5 {
+		t.Fatalf("offset = %d, want 5", got.Offset)
+	}
+}
+
+func TestManagerWriteChunkChecksum(t *testing.T) {
+	m, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := m.Create(5, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256([]byte("hello"))
+	good := "sha256 " + base64.StdEncoding.EncodeToString(sum[:])
+	if _, err := m.WriteChunk(info.ID, 0, strings.NewReader("hello"), good); err != nil {
+		t.Fatalf("valid checksum: %v", err)
+	}
+
+	info2, err := m.Create(5, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad := "sha256 " + base64.StdEncoding.EncodeToString([]byte("wrong digest, wrong len!"))
+	if _, err := m.WriteChunk(info2.ID, 0, strings.NewReader("hello"), bad); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("bad checksum: got %v, want ErrChecksumMismatch", err)
+	}
+}
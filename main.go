@@ -1,7 +1,14 @@
 package main
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
 	"embed"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -14,19 +21,81 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
+
+	"yeka/fileshare/internal/auth"
+	"yeka/fileshare/internal/index"
+	"yeka/fileshare/internal/scan"
+	"yeka/fileshare/internal/storage"
+	"yeka/fileshare/internal/upload"
 )
 
 type Config struct {
 	DisableDirectoryListing bool
 	DontRemoveOnError bool
 	BasePath string
+	// StorageDriver selects the backend behind BasePath: "localfs"
+	// (default) or "s3". StorageSource must match.
+	StorageDriver string
+	// StorageSource is a URL-like location for StorageDriver:
+	// "file:///srv/data" for localfs, "s3://bucket/prefix?region=..."
+	// for s3. Defaults to BasePath under the local driver.
+	StorageSource string
+	// UploadStagingDir holds in-progress resumable uploads (a .part file
+	// plus a JSON sidecar per upload). Defaults to BasePath/.uploads.
+	UploadStagingDir string
+	// IndexRefreshInterval controls how often the background search index
+	// rebuilds. Zero means the default of 5 minutes; a negative value
+	// disables periodic refresh.
+	IndexRefreshInterval time.Duration
+	// IndexMaxSize caps the number of files tracked by the search index;
+	// zero means unlimited.
+	IndexMaxSize int
+	// ClamAVAddress is a "host:port" or Unix socket path for clamd.
+	// Leaving it empty disables virus scanning.
+	ClamAVAddress string
+	// ScanMaxBytes caps how many bytes of an upload are sent to clamd;
+	// zero means the whole file is scanned.
+	ScanMaxBytes int64
+	// QuarantineDir receives files clamd flags as infected, as a path
+	// within the configured Storage backend (relative to BasePath); if
+	// empty, flagged files are deleted instead.
+	QuarantineDir string
+	// FailClosed rejects an upload when the scanner itself errors (e.g.
+	// clamd unreachable); by default such uploads are accepted unscanned.
+	FailClosed bool
+	// AuthFile, if set, enables Basic/bearer-token login against a
+	// YAML/JSON user file at this path (see internal/auth). Leaving it
+	// empty disables authentication entirely.
+	AuthFile string
+	// ShareSecret, if set, enables signed share links (POST /share, GET
+	// /s/{token}), HMAC-signed with this key.
+	ShareSecret string
+	// ShareDBPath is where share-link download counters are persisted.
+	// Defaults to BasePath/.shares.db.
+	ShareDBPath string
 }
 
+// ErrInfected is returned by saveFile when the configured scanner flags an
+// upload; wrapped with the signature name clamd reported.
+var ErrInfected = errors.New("upload rejected: infected")
+
+// tusVersion is the tus protocol version implemented by the resumable
+// upload endpoints.
+const tusVersion = "1.0.0"
+
 //go:embed web
 var web embed.FS
 
 var config = Config{}
 
+var uploadMgr *upload.Manager
+var searchIndex *index.Index
+var scanner scan.Scanner
+var store storage.Storage
+var authStore *auth.Store
+var shareMgr *auth.ShareManager
+
 func main() {
 	mux := http.NewServeMux()
 	fsys, err := fs.Sub(web, "web")
@@ -34,11 +103,69 @@ func main() {
 		panic(err)
 	}
 
+	stagingDir := config.UploadStagingDir
+	if stagingDir == "" {
+		stagingDir = filepath.Join(config.BasePath, ".uploads")
+	}
+	uploadMgr, err = upload.NewManager(stagingDir)
+	if err != nil {
+		panic(err)
+	}
+
+	refreshInterval := config.IndexRefreshInterval
+	if refreshInterval == 0 {
+		refreshInterval = 5 * time.Minute
+	}
+	searchIndex = index.New(config.BasePath, refreshInterval, config.IndexMaxSize)
+	go searchIndex.Start(nil)
+
+	if config.ClamAVAddress != "" {
+		scanner = scan.NewClamAV(config.ClamAVAddress, 30*time.Second)
+	}
+
+	storageSource := config.StorageSource
+	if storageSource == "" {
+		storageSource = config.BasePath
+	}
+	store, err = storage.Open(config.StorageDriver, storageSource)
+	if err != nil {
+		panic(err)
+	}
+
+	if config.AuthFile != "" {
+		authStore, err = auth.LoadUsers(config.AuthFile)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	if config.ShareSecret != "" {
+		shareDBPath := config.ShareDBPath
+		if shareDBPath == "" {
+			shareDBPath = filepath.Join(config.BasePath, ".shares.db")
+		}
+		shareMgr, err = auth.NewShareManager([]byte(config.ShareSecret), shareDBPath)
+		if err != nil {
+			panic(err)
+		}
+	}
+
 	mux.Handle("/", http.FileServer(http.FS(fsys)))
 	mux.HandleFunc("/list", handleList)
 	mux.HandleFunc("/upload", handleUpload)
+	mux.HandleFunc("/upload/", handleResumableUpload)
+	mux.HandleFunc("/search", handleSearch)
+	mux.HandleFunc("/archive", handleArchive)
+	mux.HandleFunc("/share", handleShareCreate)
+	mux.HandleFunc("/s/", handleShareServe)
+
+	var handler http.Handler = mux
+	if authStore != nil {
+		handler = authStore.Middleware(mux)
+	}
+
 	log.Println("Server ready")
-	log.Println(http.ListenAndServe(":8123", mux))
+	log.Println(http.ListenAndServe(":8123", handler))
 }
 
 func handleList(w http.ResponseWriter, r *http.Request) {
@@ -52,24 +179,41 @@ func handleList(w http.ResponseWriter, r *http.Request) {
 		responseError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	if !authorized(r, path, false) {
+		responseError(w, http.StatusForbidden, "not authorized")
+		return
+	}
 
 	if !strings.HasSuffix(path, "/") {
 		// Download
 		log.Println("Downloading " + path)
+		f, err := store.Open(storagePath(path))
+		if err != nil {
+			responseError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		defer func() { _ = f.Close() }()
+
+		fi, err := store.Stat(storagePath(path))
+		if err != nil {
+			responseError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
 		cd := mime.FormatMediaType("attachment", map[string]string{"filename": filepath.Base(path)})
 		w.Header().Set("Content-Disposition", cd)
 		w.Header().Set("Content-Type", "application/octet-stream")
-		http.ServeFile(w, r, path)
+		http.ServeContent(w, r, filepath.Base(path), fi.ModTime(), f)
 		return
 	}
 
-	fsys, err := os.ReadDir(path)
+	entries, err := store.ReadDir(storagePath(path))
 	if err != nil {
 		responseError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 	paths := make([]string, 0)
-	for _, f := range fsys {
+	for _, f := range entries {
 		if strings.HasPrefix(f.Name(), ".") {
 			continue
 		}
@@ -83,12 +227,275 @@ func handleList(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write([]byte("[" + strings.Join(paths, ",") + "]"))
 }
 
+// searchHit is one entry of a /search response.
+type searchHit struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modtime"`
+}
+
+func handleSearch(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	// The index's Path filter is a subtree prefix, not necessarily an
+	// existing directory, so it can't be routed through validatePath's
+	// existence check — but it still needs the same dot-segment
+	// sanitization before it's used to scope the authorization check.
+	rel, err := sanitizeRelPath(q.Get("path"))
+	if err != nil {
+		responseError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	searchPath := filepath.Join(config.BasePath, rel) + "/"
+	if !authorized(r, searchPath, false) {
+		responseError(w, http.StatusForbidden, "not authorized")
+		return
+	}
+
+	limit := 0
+	if l := q.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 0 {
+			responseError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = n
+	}
+
+	items, err := searchIndex.Search(index.Query{
+		Term:   q.Get("q"),
+		Path:   q.Get("path"),
+		Ext:    q.Get("ext"),
+		Limit:  limit,
+		Regexp: q.Get("regexp") == "1" || q.Get("regexp") == "true",
+	})
+	if err != nil {
+		responseError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	hits := make([]searchHit, 0, len(items))
+	for _, it := range items {
+		hits = append(hits, searchHit{Path: it.Path, Size: it.Info.Size(), ModTime: it.Info.ModTime()})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(hits)
+}
+
+// handleArchive streams a zip or tar.gz archive of a directory (GET,
+// ?format=zip|tar.gz against a directory path) or of an explicit set of
+// entries (POST with a JSON body {"paths":[...]}, each relative to the
+// optional ?path= directory).
+func handleArchive(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format != "zip" && format != "tar.gz" {
+		responseError(w, http.StatusBadRequest, "format must be zip or tar.gz")
+		return
+	}
+
+	baseDir, err := validatePath(config.BasePath, r.URL.Query().Get("path"))
+	if err != nil {
+		responseError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !strings.HasSuffix(baseDir, "/") {
+		responseError(w, http.StatusBadRequest, "not a directory")
+		return
+	}
+	if !authorized(r, baseDir, false) {
+		responseError(w, http.StatusForbidden, "not authorized")
+		return
+	}
+
+	var entries []string
+	switch r.Method {
+	case http.MethodGet:
+		entries = []string{"."}
+	case http.MethodPost:
+		var body struct {
+			Paths []string `json:"paths"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			responseError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if len(body.Paths) == 0 {
+			responseError(w, http.StatusBadRequest, "paths must not be empty")
+			return
+		}
+		entries = body.Paths
+	default:
+		responseError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	name := strings.TrimSuffix(filepath.Base(strings.TrimSuffix(baseDir, "/")), "/")
+	if name == "." || name == "" {
+		name = "archive"
+	}
+	ext := ".zip"
+	if format == "tar.gz" {
+		ext = ".tar.gz"
+	}
+	cd := mime.FormatMediaType("attachment", map[string]string{"filename": name + ext})
+	w.Header().Set("Content-Disposition", cd)
+	w.Header().Set("Transfer-Encoding", "chunked")
+
+	var archiveErr error
+	if format == "zip" {
+		w.Header().Set("Content-Type", "application/zip")
+		archiveErr = writeZipArchive(r.Context(), w, baseDir, entries)
+	} else {
+		w.Header().Set("Content-Type", "application/gzip")
+		archiveErr = writeTarGzArchive(r.Context(), w, baseDir, entries)
+	}
+	if archiveErr != nil {
+		// headers (and likely some body bytes) are already on the wire, so
+		// there's nothing left to do but log and let the connection drop.
+		log.Println("archive aborted:", archiveErr)
+	}
+}
+
+// archiveEntry is a single file or directory queued for an archive,
+// expressed relative to the directory being archived.
+type archiveEntry struct {
+	name string // slash-separated, relative to baseDir
+	path string // absolute filesystem path
+	info fs.FileInfo
+}
+
+// walkArchiveEntries resolves each of entries (relative to baseDir) and
+// walks it, invoking fn for every file and directory found. Dotfiles and
+// symlinks are skipped, matching the invariants validatePath and handleList
+// already enforce elsewhere. The walk aborts as soon as ctx is done, so a
+// client disconnect stops it early.
+func walkArchiveEntries(ctx context.Context, baseDir string, entries []string, fn func(archiveEntry) error) error {
+	for _, rel := range entries {
+		full, err := validatePath(baseDir, rel)
+		if err != nil {
+			return err
+		}
+
+		err = filepath.WalkDir(strings.TrimSuffix(full, "/"), func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
+			if strings.HasPrefix(d.Name(), ".") {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.Type()&fs.ModeSymlink != 0 {
+				return nil
+			}
+
+			relName, err := filepath.Rel(baseDir, p)
+			if err != nil {
+				return err
+			}
+			if relName == "." {
+				return nil
+			}
+
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			return fn(archiveEntry{name: filepath.ToSlash(relName), path: p, info: info})
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeZipArchive(ctx context.Context, w io.Writer, baseDir string, entries []string) error {
+	zw := zip.NewWriter(w)
+	defer func() { _ = zw.Close() }()
+
+	return walkArchiveEntries(ctx, baseDir, entries, func(e archiveEntry) error {
+		header, err := zip.FileInfoHeader(e.info)
+		if err != nil {
+			return err
+		}
+		header.Name = e.name
+		if e.info.IsDir() {
+			header.Name += "/"
+		} else {
+			header.Method = zip.Deflate
+		}
+
+		dest, err := zw.CreateHeader(header)
+		if err != nil || e.info.IsDir() {
+			return err
+		}
+
+		f, err := os.Open(e.path)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		_, err = io.Copy(dest, f)
+		return err
+	})
+}
+
+func writeTarGzArchive(ctx context.Context, w io.Writer, baseDir string, entries []string) error {
+	gz := gzip.NewWriter(w)
+	defer func() { _ = gz.Close() }()
+	tw := tar.NewWriter(gz)
+	defer func() { _ = tw.Close() }()
+
+	return walkArchiveEntries(ctx, baseDir, entries, func(e archiveEntry) error {
+		header, err := tar.FileInfoHeader(e.info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = e.name
+		if e.info.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if e.info.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(e.path)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+}
+
 func handleUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Upload-Length") != "" {
+		handleUploadCreate(w, r)
+		return
+	}
+
 	path, err := validatePath(config.BasePath, r.URL.Query().Get("path"))
 	if err != nil {
 		responseError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	if !authorized(r, path, true) {
+		responseError(w, http.StatusForbidden, "not authorized")
+		return
+	}
 
 	if !strings.HasSuffix(path, "/") {
 		responseError(w, http.StatusBadRequest, "not a directory")
@@ -108,8 +515,12 @@ func handleUpload(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if part.FormName() == "myFile" {
-			if err := upload(path, part); err != nil {
-				responseError(w, http.StatusBadRequest, "upload failed: "+err.Error())
+			if err := saveMultipartFile(path, part); err != nil {
+				status := http.StatusBadRequest
+				if errors.Is(err, ErrInfected) {
+					status = http.StatusUnprocessableEntity
+				}
+				responseError(w, status, "upload failed: "+err.Error())
 			}
 			_ = part.Close()
 			return
@@ -119,19 +530,30 @@ func handleUpload(w http.ResponseWriter, r *http.Request) {
 	responseError(w, http.StatusBadRequest, "unknown payload")
 }
 
-func validatePath(base, p string) (string, error) {
+// sanitizeRelPath cleans a client-supplied relative path and rejects any
+// dot-segment, including "..", so it can't escape the directory it is
+// later joined under.
+func sanitizeRelPath(p string) (string, error) {
 	p = filepath.Clean(p)
-	if p != "." && p != "" {
-		ss := strings.Split(p, "/")
-		for _, s := range ss {
-			if strings.HasPrefix(s, ".") { // this includes ..
-				return "", errors.New("invalid path: " + s)
-			}
+	if p == "." || p == "" {
+		return "", nil
+	}
+	for _, s := range strings.Split(p, "/") {
+		if strings.HasPrefix(s, ".") { // this includes ".."
+			return "", errors.New("invalid path: " + s)
 		}
 	}
+	return p, nil
+}
+
+func validatePath(base, p string) (string, error) {
+	p, err := sanitizeRelPath(p)
+	if err != nil {
+		return "", err
+	}
 
 	p = filepath.Join(base, p)
-	fi, err := os.Stat(p)
+	fi, err := store.Stat(storagePath(p))
 	if err != nil {
 		var pe *os.PathError
 		if errors.As(err, &pe) {
@@ -147,20 +569,163 @@ func validatePath(base, p string) (string, error) {
 	return p, nil
 }
 
+// storagePath converts a validatePath-style path, which is always rooted
+// at config.BasePath, into the slash-separated path relative to it that
+// the Storage interface expects.
+func storagePath(p string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(p, config.BasePath), "/")
+}
+
 func responseError(w http.ResponseWriter, statusCode int, msg string) {
 	w.WriteHeader(statusCode)
 	_, _ = w.Write([]byte(msg))
 }
 
-func upload(path string, part *multipart.Part) error {
-	fileName := part.FileName()
+// authorized reports whether the user attached to r's context (if any) may
+// access path, consulting the nearest ".fileshare.yml" overlay. With no
+// AuthFile configured, every request is authorized, preserving today's
+// behavior.
+func authorized(r *http.Request, path string, write bool) bool {
+	if authStore == nil {
+		return true
+	}
+	user, _ := auth.UserFromContext(r.Context())
+	return authStore.Authorize(user, path, config.BasePath, write)
+}
+
+// handleShareCreate issues a signed share link for an existing file the
+// caller may read: POST /share {"path", "expires", "max_downloads"}
+// returns {"url": "/s/{token}"}.
+func handleShareCreate(w http.ResponseWriter, r *http.Request) {
+	if shareMgr == nil {
+		responseError(w, http.StatusNotImplemented, "sharing is not configured")
+		return
+	}
+
+	var body struct {
+		Path         string    `json:"path"`
+		Expires      time.Time `json:"expires"`
+		MaxDownloads int       `json:"max_downloads"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		responseError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	path, err := validatePath(config.BasePath, body.Path)
+	if err != nil {
+		responseError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if strings.HasSuffix(path, "/") {
+		responseError(w, http.StatusBadRequest, "cannot share a directory")
+		return
+	}
+	if !authorized(r, path, false) {
+		responseError(w, http.StatusForbidden, "not authorized")
+		return
+	}
+
+	token, err := shareMgr.Create(storagePath(path), body.Expires, body.MaxDownloads)
+	if err != nil {
+		responseError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"url": "/s/" + token})
+}
+
+// handleShareServe serves the file behind a share token created by
+// handleShareCreate, with no auth required, until it expires or exhausts
+// its download limit.
+func handleShareServe(w http.ResponseWriter, r *http.Request) {
+	if shareMgr == nil {
+		responseError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	token := strings.TrimPrefix(r.URL.Path, "/s/")
+	link, err := shareMgr.Resolve(token)
+	if err != nil {
+		responseError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	f, err := store.Open(link.Path)
+	if err != nil {
+		responseError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	fi, err := store.Stat(link.Path)
+	if err != nil {
+		responseError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	cd := mime.FormatMediaType("attachment", map[string]string{"filename": filepath.Base(link.Path)})
+	w.Header().Set("Content-Disposition", cd)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, filepath.Base(link.Path), fi.ModTime(), f)
+}
+
+// saveMultipartFile stores one "myFile" part from a classic multipart
+// /upload request, named to avoid colliding with the internal/upload
+// package this file also imports.
+func saveMultipartFile(path string, part *multipart.Part) error {
+	return saveFile(path, part.FileName(), part)
+}
+
+// saveFile stages r into a temp file inside the directory path, scanning it
+// (if a scanner is configured) concurrently with the write, then renames it
+// into path/fileName via the configured Storage backend. If that name is
+// taken it tries "name (1).ext", then appends to the last candidate:
+// "name (1) (2).ext", "name (1) (2) (3).ext", etc.
+func saveFile(path, fileName string, r io.Reader) error {
+	fileName = filepath.Base(fileName)
+	if fileName == "." || fileName == ".." || fileName == "/" || fileName == "" {
+		return errors.New("invalid file name")
+	}
+
+	tmpPath := filepath.Join(path, tempName())
+	tmp, err := store.Create(storagePath(tmpPath))
+	if err != nil {
+		return err
+	}
+	keep := false
+	defer func() {
+		_ = tmp.Close()
+		if !keep {
+			_ = store.Remove(storagePath(tmpPath))
+		}
+	}()
+
+	n, infected, signature, err := writeAndScan(tmp, r)
+	if err != nil {
+		keep = config.DontRemoveOnError
+		fmt.Println(tmpPath, n, err)
+		return err
+	}
+	if infected {
+		if err := quarantine(tmpPath); err != nil {
+			return err
+		}
+		keep = true // already moved out of the temp path by quarantine
+		return fmt.Errorf("%w: %s", ErrInfected, signature)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
 	ext := filepath.Ext(fileName)
 	file := filepath.Join(path, fileName)
 
 	// check if file exists
 	i := 0
 	for {
-		_, err := os.Stat(file)
+		_, err := store.Stat(storagePath(file))
 		if errors.Is(err, os.ErrNotExist) {
 			break
 		}
@@ -172,18 +737,268 @@ func upload(path string, part *multipart.Part) error {
 		file = file[:len(file)-len(ext)] + " (" + strconv.Itoa(i) + ")" + ext
 	}
 
-	f, err := os.Create(file)
-	if err != nil {
+	if err := store.Rename(storagePath(tmpPath), storagePath(file)); err != nil {
+		keep = config.DontRemoveOnError
 		return err
 	}
-	defer func() {
-		_ = f.Close()
+	keep = true
+	fmt.Println(file, n, error(nil))
+	return nil
+}
+
+// tempName returns a random staging-file name for saveFile.
+func tempName() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return ".upload-" + hex.EncodeToString(b) + ".tmp"
+}
+
+// writeAndScan copies r into dst, mirroring the bytes to the configured
+// scanner (if any) as they are written so scanning does not add an extra
+// pass over the data. ScanMaxBytes, if set, caps how much of the stream is
+// sent to the scanner without truncating the copy to dst.
+func writeAndScan(dst io.Writer, r io.Reader) (n int64, infected bool, signature string, err error) {
+	if scanner == nil {
+		n, err = io.Copy(dst, r)
+		return n, false, "", err
+	}
+
+	pr, pw := io.Pipe()
+	var scanSrc io.Reader = pr
+	if config.ScanMaxBytes > 0 {
+		scanSrc = io.LimitReader(pr, config.ScanMaxBytes)
+	}
+
+	type outcome struct {
+		res scan.Result
+		err error
+	}
+	resultCh := make(chan outcome, 1)
+	go func() {
+		res, err := scanner.Scan(scanSrc)
+		_, _ = io.Copy(io.Discard, pr) // drain so the writer below never blocks
+		resultCh <- outcome{res, err}
 	}()
-	n, err := io.Copy(f, part)
-	if err != nil && !config.DontRemoveOnError {
-		// should be optional
-		_ = os.Remove(file)
+
+	n, copyErr := io.Copy(io.MultiWriter(dst, pw), r)
+	_ = pw.Close()
+	out := <-resultCh
+
+	if copyErr != nil {
+		return n, false, "", copyErr
+	}
+	if out.err != nil {
+		if config.FailClosed {
+			return n, false, "", fmt.Errorf("scan: %w", out.err)
+		}
+		log.Println("scan error, accepting unscanned (fail-open):", out.err)
+		return n, false, "", nil
+	}
+	return n, out.res.Infected, out.res.Signature, nil
+}
+
+// quarantine moves a rejected upload to Config.QuarantineDir (a path
+// within the configured Storage backend, relative to BasePath), or deletes
+// it if no quarantine directory is configured.
+func quarantine(tmpPath string) error {
+	if config.QuarantineDir == "" {
+		return store.Remove(storagePath(tmpPath))
+	}
+	dest := filepath.Join(config.BasePath, config.QuarantineDir, filepath.Base(tmpPath))
+	return store.Rename(storagePath(tmpPath), storagePath(dest))
+}
+
+// handleUploadCreate implements the tus creation extension: POST /upload
+// with an Upload-Length header (and optional Upload-Metadata) starts a new
+// resumable upload and returns its location.
+func handleUploadCreate(w http.ResponseWriter, r *http.Request) {
+	size, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil {
+		responseError(w, http.StatusBadRequest, "invalid Upload-Length")
+		return
+	}
+
+	info, err := uploadMgr.Create(size, r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		responseError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !authorizedForUpload(r, info, true) {
+		_ = uploadMgr.Terminate(info.ID)
+		responseError(w, http.StatusForbidden, "not authorized")
+		return
+	}
+
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Location", "/upload/"+info.ID)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// handleResumableUpload serves HEAD (offset query), PATCH (chunk append)
+// and DELETE (termination) for a single in-progress upload at /upload/{id}.
+func handleResumableUpload(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/upload/")
+	if id == "" || strings.Contains(id, "/") {
+		responseError(w, http.StatusBadRequest, "invalid upload id")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodHead:
+		handleUploadHead(w, id)
+	case http.MethodPatch:
+		handleUploadPatch(w, r, id)
+	case http.MethodDelete:
+		handleUploadDelete(w, r, id)
+	default:
+		responseError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func handleUploadHead(w http.ResponseWriter, id string) {
+	info, err := uploadMgr.Get(id)
+	if err != nil {
+		responseError(w, uploadStatusCode(err), err.Error())
+		return
+	}
+
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(info.Size, 10))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+}
+
+func handleUploadPatch(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		responseError(w, http.StatusUnsupportedMediaType, "unsupported content type")
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		responseError(w, http.StatusBadRequest, "invalid Upload-Offset")
+		return
+	}
+
+	existing, err := uploadMgr.Get(id)
+	if err != nil {
+		responseError(w, uploadStatusCode(err), err.Error())
+		return
+	}
+	if !authorizedForUpload(r, existing, true) {
+		responseError(w, http.StatusForbidden, "not authorized")
+		return
+	}
+
+	info, err := uploadMgr.WriteChunk(id, offset, r.Body, r.Header.Get("Upload-Checksum"))
+	if err != nil {
+		responseError(w, uploadStatusCode(err), err.Error())
+		return
+	}
+
+	if info.Offset == info.Size {
+		if err := finishUpload(info); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrInfected) {
+				status = http.StatusUnprocessableEntity
+			}
+			responseError(w, status, "upload completion failed: "+err.Error())
+			return
+		}
+	}
+
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleUploadDelete(w http.ResponseWriter, r *http.Request, id string) {
+	existing, err := uploadMgr.Get(id)
+	if err != nil {
+		responseError(w, uploadStatusCode(err), err.Error())
+		return
+	}
+	if !authorizedForUpload(r, existing, true) {
+		responseError(w, http.StatusForbidden, "not authorized")
+		return
+	}
+
+	if err := uploadMgr.Terminate(id); err != nil {
+		responseError(w, uploadStatusCode(err), err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// uploadDestDir resolves and validates the directory a resumable upload's
+// Upload-Metadata declares as its destination, the same way a regular
+// upload's ?path= is validated.
+func uploadDestDir(info *upload.Info) (string, error) {
+	destDir, err := validatePath(config.BasePath, info.Metadata["path"])
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasSuffix(destDir, "/") {
+		return "", errors.New("upload destination is not a directory")
+	}
+	return destDir, nil
+}
+
+// authorizedForUpload reports whether r's caller may act on a resumable
+// upload whose Upload-Metadata names its destination directory, failing
+// closed (denying) if that directory can't be resolved at all — e.g. an
+// invalid or dot-segment path — rather than letting the check be skipped.
+func authorizedForUpload(r *http.Request, info *upload.Info, write bool) bool {
+	destDir, err := uploadDestDir(info)
+	if err != nil {
+		return false
+	}
+	return authorized(r, destDir, write)
+}
+
+// finishUpload moves a completed resumable upload's staged bytes into
+// BasePath using the same collision-avoidance rename logic as a regular
+// upload, then clears its staging files. Authorization is checked by the
+// caller (handleUploadPatch) before any chunk is written, using the same
+// destination directory uploadDestDir resolves here.
+func finishUpload(info *upload.Info) error {
+	destDir, err := uploadDestDir(info)
+	if err != nil {
+		return err
+	}
+	if info.Filename == "" {
+		return errors.New("missing filename in Upload-Metadata")
+	}
+
+	_, partPath, err := uploadMgr.Complete(info.ID)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(partPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	if err := saveFile(destDir, info.Filename, f); err != nil {
+		return err
+	}
+
+	return uploadMgr.Finalize(info.ID)
+}
+
+func uploadStatusCode(err error) int {
+	switch {
+	case errors.Is(err, upload.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, upload.ErrOffsetMismatch), errors.Is(err, upload.ErrIncomplete):
+		return http.StatusConflict
+	case errors.Is(err, upload.ErrChecksumMismatch):
+		return 460 // tus checksum mismatch
+	case errors.Is(err, upload.ErrTooLarge):
+		return http.StatusRequestEntityTooLarge
+	default:
+		return http.StatusInternalServerError
 	}
-	fmt.Println(file, n, err)
-	return err
 }
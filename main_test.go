@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"yeka/fileshare/internal/storage"
+)
+
+// withTestStore points config.BasePath and store at a temp directory for
+// the duration of a test, restoring the previous values afterward.
+func withTestStore(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+
+	prevBasePath, prevStore := config.BasePath, store
+	config.BasePath = root
+	store = storage.NewLocalFS(root)
+	t.Cleanup(func() {
+		config.BasePath = prevBasePath
+		store = prevStore
+	})
+
+	return root
+}
+
+func writeTestFile(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWalkArchiveEntriesNestedAndEmptyDirs(t *testing.T) {
+	root := withTestStore(t)
+
+	writeTestFile(t, filepath.Join(root, "a.txt"), "hello")
+	writeTestFile(t, filepath.Join(root, "nested", "b.txt"), "world")
+	writeTestFile(t, filepath.Join(root, "nested", "sub", "c.txt"), "!")
+	if err := os.MkdirAll(filepath.Join(root, "empty"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	err := walkArchiveEntries(context.Background(), root+"/", []string{"."}, func(e archiveEntry) error {
+		names = append(names, e.name)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walkArchiveEntries: %v", err)
+	}
+
+	want := []string{"a.txt", "empty", "nested", "nested/b.txt", "nested/sub", "nested/sub/c.txt"}
+	for _, w := range want {
+		if !containsString(names, w) {
+			t.Errorf("walk result %v missing entry %q", names, w)
+		}
+	}
+}
+
+func TestWalkArchiveEntriesCancellation(t *testing.T) {
+	root := withTestStore(t)
+	writeTestFile(t, filepath.Join(root, "a.txt"), "hello")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel() // simulate a client disconnect before the walk starts
+
+	err := walkArchiveEntries(ctx, root+"/", []string{"."}, func(archiveEntry) error {
+		t.Fatal("fn should not run once ctx is done")
+		return nil
+	})
+	if err != context.Canceled {
+		t.Fatalf("got err %v, want context.Canceled", err)
+	}
+}
+
+func containsString(haystack []string, needle string) bool {
+	for _, s := range haystack {
+		if s == needle {
+			return true
+		}
+	}
+	return false
+}